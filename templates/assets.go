@@ -0,0 +1,94 @@
+package templates
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bakape/meguca/util"
+)
+
+var (
+	assetsMu sync.RWMutex
+
+	// assetManifest maps a logical asset name ("client.js") to its
+	// content-hash-addressed public path ("/assets/client.1a2b3c4d.js"),
+	// replacing the old opaque clientFileHash global
+	assetManifest = map[string]string{}
+)
+
+// defaultAssets backs the package-level asset manifest and is the one used
+// in production, reading client files straight off disk. It is used by
+// every Compiler whose Assets field is nil, whatever its FS, so a Compiler
+// with an embedded or in-memory FS and no Assets still hashes client files
+// from the www directory on disk.
+var defaultAssets fs.FS = os.DirFS("www")
+
+// loadAssets walks c.Assets (or defaultAssets, if unset), hashes every file
+// and records its content-hash-addressed public path in assetManifest, so
+// templates can reference versioned JS/CSS via {{asset "client.js"}} and
+// servers can set a long-lived Cache-Control: immutable on the hashed
+// path. The index store's own hash already depends on its contents, so it
+// is automatically invalidated whenever an asset reference inside it
+// changes.
+//
+// A missing or unreadable assets root is not fatal to the template
+// pipeline - it is logged and leaves assetManifest empty, the same way
+// loadTranslations tolerates a missing lang/<locale>.json, so pages that
+// reference no assets still compile before the frontend build step has
+// ever run.
+func (c *Compiler) loadAssets() error {
+	root := c.Assets
+	if root == nil {
+		root = defaultAssets
+	}
+	manifest := map[string]string{}
+	err := fs.WalkDir(root, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		b, err := fs.ReadFile(root, p)
+		if err != nil {
+			return err
+		}
+		hash, err := util.HashBuffer(b)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(p)
+		name := strings.TrimSuffix(p, ext)
+		manifest[p] = fmt.Sprintf("/assets/%s.%s%s", name, hash, ext)
+		return nil
+	})
+	if err != nil {
+		log.Printf("templates: error hashing client assets, serving without an asset manifest: %s\n", err)
+		assetsMu.Lock()
+		assetManifest = map[string]string{}
+		assetsMu.Unlock()
+		return nil
+	}
+
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	assetManifest = manifest
+	return nil
+}
+
+// asset resolves a logical asset name to its content-hash-addressed public
+// path, for embedding in templates via {{asset "client.js"}}. Falls back to
+// the unhashed path if name is not in the manifest.
+func asset(name string) string {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	if p, ok := assetManifest[name]; ok {
+		return p
+	}
+	return "/assets/" + name
+}
@@ -0,0 +1,39 @@
+package templates
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadAssets(t *testing.T) {
+	c := &Compiler{
+		Assets: fstest.MapFS{
+			"client.js": &fstest.MapFile{Data: []byte("console.log(1)")},
+		},
+	}
+	if err := c.loadAssets(); err != nil {
+		t.Fatal(err)
+	}
+	if got := asset("client.js"); got == "/assets/client.js" {
+		t.Fatal("expected a content-hash-addressed path, got the unhashed fallback")
+	}
+}
+
+// erroringFS simulates an assets root that doesn't exist yet, e.g. a fresh
+// checkout before the client build step has run
+type erroringFS struct{}
+
+func (erroringFS) Open(name string) (fs.File, error) {
+	return nil, fs.ErrNotExist
+}
+
+func TestLoadAssetsMissingRoot(t *testing.T) {
+	c := &Compiler{Assets: erroringFS{}}
+	if err := c.loadAssets(); err != nil {
+		t.Fatalf("a missing assets root must not fail Compile: %s", err)
+	}
+	if got := asset("client.js"); got != "/assets/client.js" {
+		t.Errorf("asset() = %q, want the unhashed fallback", got)
+	}
+}
@@ -0,0 +1,158 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/bakape/meguca/util"
+)
+
+var (
+	funcsMu sync.RWMutex
+
+	// extraFuncs holds functions registered via Funcs, merged into every
+	// page's FuncMap before parsing
+	extraFuncs = template.FuncMap{}
+
+	// translations holds the i18n lookup table, loaded fresh on every
+	// Compile from lang/<locale>.json
+	translations = map[string]map[string]string{}
+)
+
+// Funcs registers additional template functions, merged into the FuncMap
+// every page template is parsed with. Call before Compile, so the
+// registered functions are available to all pages.
+func Funcs(fm template.FuncMap) {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	for name, fn := range fm {
+		extraFuncs[name] = fn
+	}
+}
+
+// funcMap builds the FuncMap a page template for locale is parsed with: the
+// built-in defaults, overlaid with anything registered via Funcs
+func funcMap(locale string) template.FuncMap {
+	fm := template.FuncMap{
+		"t":           translate(locale),
+		"formatTime":  formatTime,
+		"countryName": countryName,
+		"escapeJSON":  escapeJSON,
+		"csrfToken":   csrfToken,
+		"asset":       asset,
+	}
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	for name, fn := range extraFuncs {
+		fm[name] = fn
+	}
+	return fm
+}
+
+// translate looks up key in locale's translation table, falling back to
+// the key itself if no translation is available
+func translate(locale string) func(key string) string {
+	return func(key string) string {
+		funcsMu.RLock()
+		defer funcsMu.RUnlock()
+		if table, ok := translations[locale]; ok {
+			if s, ok := table[key]; ok {
+				return s
+			}
+		}
+		return key
+	}
+}
+
+// loadTranslations reads the i18n lookup table for each of locales from
+// lang/<locale>.json in c.FS. Locales with no translation file are simply
+// left untranslated, so "t" falls back to its key argument.
+func (c *Compiler) loadTranslations(locales []string) error {
+	loaded := make(map[string]map[string]string, len(locales))
+	for _, locale := range locales {
+		if locale == "" {
+			continue
+		}
+		b, err := fs.ReadFile(c.FS, fmt.Sprintf("lang/%s.json", locale))
+		if err != nil {
+			continue
+		}
+		table := map[string]string{}
+		if err := json.Unmarshal(b, &table); err != nil {
+			return util.WrapError(
+				fmt.Sprintf("error parsing translations for %s", locale), err,
+			)
+		}
+		loaded[locale] = table
+	}
+
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	translations = loaded
+	return nil
+}
+
+// formatTime renders t as a coarse relative time, the way post timestamps
+// are displayed ("3 minutes ago")
+func formatTime(t time.Time) string {
+	switch d := time.Since(t); {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%d days ago", int(d/(24*time.Hour)))
+	}
+}
+
+// countryNames maps ISO 3166-1 alpha-2 codes to display names, for the
+// country flags shown next to posts
+var countryNames = map[string]string{
+	"US": "United States",
+	"GB": "United Kingdom",
+	"DE": "Germany",
+	"FR": "France",
+	"JP": "Japan",
+	"KR": "South Korea",
+	"RU": "Russia",
+	"CN": "China",
+}
+
+// countryName resolves an ISO 3166-1 alpha-2 code to its display name,
+// falling back to the code itself if it is not known
+func countryName(code string) string {
+	if name, ok := countryNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// escapeJSON marshals v to JSON, for safely embedding data inside a
+// <script> tag
+func escapeJSON(v interface{}) (template.JS, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", util.WrapError("error marshalling JSON for template", err)
+	}
+	return template.JS(b), nil
+}
+
+// CSRFTokenPlaceholder marks where a per-request CSRF token belongs in a
+// compiled page. Stores are compiled once and served byte-for-byte to
+// every visitor until the next recompile, so the real, per-session token
+// can never be baked in here - that would make it the same replayable
+// value for every visitor. The HTTP handler serving a Store must splice
+// the real token in over this placeholder before writing the response.
+const CSRFTokenPlaceholder = "{{csrfToken}}"
+
+// csrfToken emits CSRFTokenPlaceholder. See its doc comment for why the
+// real, per-request token can't be filled in at compile time.
+func csrfToken() string {
+	return CSRFTokenPlaceholder
+}
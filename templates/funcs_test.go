@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	cases := []struct {
+		age  time.Duration
+		want string
+	}{
+		{30 * time.Second, "just now"},
+		{5 * time.Minute, "5 minutes ago"},
+		{3 * time.Hour, "3 hours ago"},
+		{48 * time.Hour, "2 days ago"},
+	}
+	for _, c := range cases {
+		got := formatTime(time.Now().Add(-c.age))
+		if got != c.want {
+			t.Errorf("formatTime(%s ago) = %q, want %q", c.age, got, c.want)
+		}
+	}
+}
+
+func TestCountryName(t *testing.T) {
+	if got := countryName("JP"); got != "Japan" {
+		t.Errorf(`countryName("JP") = %q, want "Japan"`, got)
+	}
+	if got := countryName("ZZ"); got != "ZZ" {
+		t.Errorf(`countryName("ZZ") = %q, want fallback "ZZ"`, got)
+	}
+}
+
+func TestEscapeJSON(t *testing.T) {
+	js, err := escapeJSON(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"a":1}` {
+		t.Errorf(`escapeJSON = %s, want {"a":1}`, js)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	if got := csrfToken(); got != CSRFTokenPlaceholder {
+		t.Errorf("csrfToken() = %q, want placeholder %q", got, CSRFTokenPlaceholder)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	funcsMu.Lock()
+	translations = map[string]map[string]string{
+		"ja": {"hello": "こんにちは"},
+	}
+	funcsMu.Unlock()
+	defer func() {
+		funcsMu.Lock()
+		translations = map[string]map[string]string{}
+		funcsMu.Unlock()
+	}()
+
+	if got := translate("ja")("hello"); got != "こんにちは" {
+		t.Errorf(`translate("ja")("hello") = %q`, got)
+	}
+	if got := translate("ja")("missing"); got != "missing" {
+		t.Errorf(`translate("ja")("missing") = %q, want fallback to key`, got)
+	}
+	if got := translate("en")("hello"); got != "hello" {
+		t.Errorf(`translate("en")("hello") = %q, want fallback to key`, got)
+	}
+}
@@ -0,0 +1,36 @@
+package templates
+
+// Key identifies one compiled template variant in the resources cache
+type Key struct {
+	Page   string
+	Board  string
+	Locale string
+	Mobile bool
+}
+
+// getOptions collects the Option values passed to Get and Set
+type getOptions struct {
+	board  string
+	locale string
+	mobile bool
+}
+
+// Option narrows down which template variant Get or Set operates on
+type Option func(*getOptions)
+
+// WithBoard selects the variant rendered for board, e.g. its own banner or
+// highlighted navigation entry. Defaults to "", the board-agnostic variant.
+func WithBoard(board string) Option {
+	return func(o *getOptions) { o.board = board }
+}
+
+// WithLocale selects the variant rendered for locale. Defaults to "", the
+// server's default locale.
+func WithLocale(locale string) Option {
+	return func(o *getOptions) { o.locale = locale }
+}
+
+// WithMobile selects the mobile variant, instead of the default desktop one
+func WithMobile(mobile bool) Option {
+	return func(o *getOptions) { o.mobile = mobile }
+}
@@ -0,0 +1,31 @@
+package templates
+
+import "testing"
+
+func TestGetSetOptions(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		resources = map[Key]Store{}
+		mu.Unlock()
+	}()
+
+	board := Store{HTML: []byte("board"), Hash: "board-hash"}
+	Set("index", board, WithBoard("g"))
+
+	if got := Get("index", WithBoard("g")); got.Hash != board.Hash {
+		t.Fatalf("WithBoard: got %+v, want %+v", got, board)
+	}
+	if got := Get("index"); got.Hash == board.Hash {
+		t.Fatal("a board-scoped Store leaked into the board-agnostic lookup")
+	}
+
+	mobile := Store{HTML: []byte("mobile"), Hash: "mobile-hash"}
+	Set("index", mobile, WithLocale("ja"), WithMobile(true))
+
+	if got := Get("index", WithLocale("ja"), WithMobile(true)); got.Hash != mobile.Hash {
+		t.Fatalf("WithLocale/WithMobile: got %+v, want %+v", got, mobile)
+	}
+	if got := Get("index", WithLocale("ja")); got.Hash == mobile.Hash {
+		t.Fatal("the desktop lookup returned the mobile Store")
+	}
+}
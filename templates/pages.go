@@ -0,0 +1,45 @@
+package templates
+
+import (
+	"sync"
+)
+
+// page describes a page template registered with RegisterPage. It is
+// composed against the shared base.html layout, which defines the
+// surrounding chrome via {{define "layout"}}...{{block "content" .}}
+// {{end}}...{{end}}
+type page struct {
+	file      string
+	extraVars func() interface{}
+}
+
+var (
+	pagesMu sync.RWMutex
+
+	// pages holds all registered page templates, keyed by name
+	pages = map[string]page{}
+)
+
+// RegisterPage registers a named page template to be compiled and cached
+// alongside the rest of resources. file is resolved relative to the
+// Compiler's FS and parsed together with base.html, so the page only needs
+// to fill in the "content" block and the surrounding chrome is never
+// duplicated. extraVars, if not nil, is called on every Compile and its
+// result is attached to the page's template data under .Extra, letting a
+// page add fields of its own without widening vars for every other page.
+func RegisterPage(name, file string, extraVars func() interface{}) {
+	pagesMu.Lock()
+	defer pagesMu.Unlock()
+	pages[name] = page{file: file, extraVars: extraVars}
+}
+
+func init() {
+	// The original single index.html becomes just another registration.
+	// thread/board/catalog render server-side for SEO and no-JS clients,
+	// sharing the same base.html chrome as index instead of duplicating it.
+	RegisterPage("index", "index.html", nil)
+	RegisterPage("thread", "thread.html", nil)
+	RegisterPage("board", "board.html", nil)
+	RegisterPage("catalog", "catalog.html", nil)
+	RegisterPage("admin", "admin.html", nil)
+}
@@ -7,19 +7,28 @@ import (
 	"github.com/bakape/meguca/config"
 	"github.com/bakape/meguca/util"
 	"github.com/dchest/htmlmin"
+	"github.com/fsnotify/fsnotify"
 	"html/template"
-	"path/filepath"
+	"io/fs"
+	"os"
 	"sync"
 )
 
 var (
-	// Overriden in tests
-	templateRoot = "templates"
-
-	// resources conatains all available templates
-	resources = map[string]Store{}
+	// resources conatains all available templates, keyed by page, board,
+	// locale and desktop/mobile
+	resources = map[Key]Store{}
 
 	mu sync.RWMutex
+
+	// defaultCompiler backs the package-level Compile() and is the one used
+	// in production, reading templates straight off disk. Deployers wanting
+	// a single-binary build construct their own Compiler with an embedded
+	// FileSystem instead.
+	defaultCompiler = &Compiler{
+		FS:   os.DirFS("templates"),
+		Root: "templates",
+	}
 )
 
 // Store stores the compiled HTML template and the corresponding truncated MD5
@@ -29,71 +38,245 @@ type Store struct {
 	Hash string
 }
 
+// Compiler reads template HTML from a FileSystem, injects dynamic
+// variables, hashes and stores them
+type Compiler struct {
+	// FS templates are parsed from. Pass an embed.FS (via a //go:embed
+	// directive) to ship templates inside the binary, or a fstest.MapFS in
+	// tests to avoid touching disk.
+	FS fs.FS
+
+	// Root is the on-disk directory FS was derived from, if any. Used only
+	// to drive the development-mode filesystem watcher; leave empty for
+	// FileSystems with no meaningful on-disk root, which simply disables
+	// hot-reload for that Compiler.
+	Root string
+
+	// Assets is the FileSystem client assets (JS, CSS, ...) are hashed
+	// from for the {{asset}} template helper. Defaults to defaultAssets
+	// when nil, so most callers never need to set it.
+	Assets fs.FS
+
+	// DevMode enables the filesystem watcher, so edits to Root are picked
+	// up without a restart. The caller (normally main, reading a CLI flag
+	// or environment variable) is responsible for setting this before the
+	// first Compile call; there is no such flag in config yet.
+	DevMode bool
+
+	// Locales lists the locales to pre-render every page for. Nil compiles
+	// only the locale-agnostic "" variant; there is no such list in config
+	// yet, so callers wanting i18n set this directly.
+	Locales []string
+
+	// watchMu guards watcher, so two goroutines calling Compile on the same
+	// Compiler can't both start a filesystem watcher for it.
+	watchMu sync.Mutex
+
+	// watcher is this Compiler's active filesystem watcher in development
+	// mode. Nil until watch() starts one. Per-Compiler, so one Compiler
+	// starting its watcher doesn't make another silently skip its own.
+	watcher *fsnotify.Watcher
+}
+
 // Compile reads template HTML from disk, injects dynamic variables,
 // hashes and stores them
 func Compile() error {
-	// Only one for now, but there will be more later
-	index, mobile, err := indexTemplate()
-	if err != nil {
+	return defaultCompiler.Compile()
+}
+
+// Compile reads template HTML from c.FS, injects dynamic variables, hashes
+// and stores them
+func (c *Compiler) Compile() error {
+	if err := c.compile(); err != nil {
+		return err
+	}
+
+	// In development mode, keep watching Root and recompile on the fly,
+	// instead of requiring a server restart for every template edit.
+	// Guarded so the production hot path is unaffected and still reads
+	// straight from the resources map.
+	if c.DevMode {
+		return c.watch()
+	}
+	return nil
+}
+
+// SetDevMode toggles hot-reload on the default Compiler used by the
+// package-level Compile(). Call once at startup, before the first Compile,
+// e.g. from a CLI flag or environment variable read in main.
+func SetDevMode(dev bool) {
+	defaultCompiler.DevMode = dev
+}
+
+// compileResult is one compiled Key/Store pair, or an error, produced by a
+// single compile worker
+type compileResult struct {
+	key Key
+	s   Store
+	err error
+}
+
+// compile performs the actual (re)compilation of all templates, pre-
+// rendering every page x board x locale x {desktop, mobile} combination in
+// parallel, so request handlers always serve a precomputed, minified,
+// hashed buffer. Split out of Compile() so the filesystem watcher can call
+// it repeatedly.
+func (c *Compiler) compile() error {
+	pagesMu.RLock()
+	snapshot := make(map[string]page, len(pages))
+	for name, p := range pages {
+		snapshot[name] = p
+	}
+	pagesMu.RUnlock()
+
+	// "" is the board-agnostic variant Get returns by default, when no
+	// WithBoard option is given; always compile it alongside the
+	// per-board ones, even when no boards are configured yet.
+	boards := append([]string{""}, config.Get().Boards.Enabled...)
+	locales := c.Locales
+	if len(locales) == 0 {
+		locales = []string{""}
+	}
+	if err := c.loadTranslations(locales); err != nil {
+		return err
+	}
+	if err := c.loadAssets(); err != nil {
 		return err
 	}
 
+	results := make(chan compileResult)
+	var wg sync.WaitGroup
+	for name, p := range snapshot {
+		for _, board := range boards {
+			for _, locale := range locales {
+				wg.Add(1)
+				go func(name string, p page, board, locale string) {
+					defer wg.Done()
+					desktop, mobile, err := c.pageTemplate(p, board, locale)
+					if err != nil {
+						results <- compileResult{
+							err: util.WrapError(
+								fmt.Sprintf("error compiling page %s", name),
+								err,
+							),
+						}
+						return
+					}
+					results <- compileResult{
+						key: Key{Page: name, Board: board, Locale: locale},
+						s:   desktop,
+					}
+					results <- compileResult{
+						key: Key{Page: name, Board: board, Locale: locale, Mobile: true},
+						s:   mobile,
+					}
+				}(name, p, board, locale)
+			}
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	compiled := make(map[Key]Store, len(snapshot)*len(boards)*len(locales)*2)
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		compiled[r.key] = r.s
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Replace resources wholesale, rather than merging: a board, locale or
+	// page that disappeared from config since the last compile must not
+	// be left reachable through a stale Key.
 	mu.Lock()
 	defer mu.Unlock()
-	resources["index"] = index
-	resources["mobile"] = mobile
+	resources = compiled
 	return nil
 }
 
-// clientFileHash is the combined, shortened MD5 hash of all client files
-var clientFileHash string
-
 type vars struct {
 	Config     template.JS
 	Navigation template.HTML
 	Email      string
 	ConfigHash string
+	Board      string
+	Locale     string
 	IsMobile   bool
 }
 
-// indexTemplate compiles the HTML template for thread and board pages of the
-// imageboard
-func indexTemplate() (desktop Store, mobile Store, err error) {
+// pageVars wraps the common vars with whatever a page's extraVars
+// contributes, so pages can reach additional fields from the "content"
+// block without widening vars for everyone
+type pageVars struct {
+	vars
+	Extra interface{}
+}
+
+// pageTemplate composes p against the shared base.html layout and compiles
+// the HTML template for the page's desktop and mobile variants, for the
+// given board and locale
+func (c *Compiler) pageTemplate(
+	p page,
+	board, locale string,
+) (desktop Store, mobile Store, err error) {
 	clientJSON, hash := config.GetClient()
-	v := vars{
-		Config:     template.JS(clientJSON),
-		ConfigHash: hash,
-		Navigation: boardNavigation(),
-		Email:      config.Get().FeedbackEmail,
-	}
-	path := filepath.FromSlash(templateRoot + "/index.html")
-	tmpl, err := template.ParseFiles(path)
+	v := pageVars{
+		vars: vars{
+			Config:     template.JS(clientJSON),
+			ConfigHash: hash,
+			Navigation: boardNavigation(board),
+			Email:      config.Get().FeedbackEmail,
+			Board:      board,
+			Locale:     locale,
+		},
+	}
+	if p.extraVars != nil {
+		v.Extra = p.extraVars()
+	}
+
+	tmpl, err := template.New("base.html").
+		Funcs(funcMap(locale)).
+		ParseFS(c.FS, "base.html", p.file)
 	if err != nil {
-		err = util.WrapError("Error parsing index temlate", err)
+		err = util.WrapError("error parsing page template", err)
 		return
 	}
 
 	// Rigt now the desktop and mobile templates are almost identical. This will
 	// change, when we get a dedicated mobile GUI.
-	desktop, err = buildIndexTemplate(tmpl, v, false)
+	desktop, err = buildPageTemplate(p.file, tmpl, v, false)
 	if err != nil {
 		return
 	}
-	mobile, err = buildIndexTemplate(tmpl, v, true)
+	mobile, err = buildPageTemplate(p.file, tmpl, v, true)
 	return
 }
 
-// boardNavigation renders interboard navigation we put in the top banner
-func boardNavigation() template.HTML {
+// boardNavigation renders interboard navigation we put in the top banner,
+// marking board as the currently active one
+func boardNavigation(board string) template.HTML {
 	html := `<b id="navTop">[`
 	conf := config.Get().Boards
 
 	// Actual boards and "/all/" metaboard
-	for i, board := range append(conf.Enabled, "all") {
-		if board == conf.Staff {
+	for i, b := range append(conf.Enabled, "all") {
+		if b == conf.Staff {
 			continue
 		}
-		html += boardLink(i > 0, board, "../"+board+"/")
+		if b == board {
+			html += boardLink(i > 0, b, "")
+		} else {
+			html += boardLink(i > 0, b, "../"+b+"/")
+		}
 	}
 
 	// Add custom URLs to board navigation
@@ -104,48 +287,72 @@ func boardNavigation() template.HTML {
 	return template.HTML(html)
 }
 
-// Builds a a board link, for the interboard navigation bar
+// Builds a a board link, for the interboard navigation bar. An empty url
+// renders the active board as bold text instead of a link to itself.
 func boardLink(notFirst bool, name, url string) string {
-	link := fmt.Sprintf(`<a href="%v">%v</a>`, url, name)
+	var link string
+	if url == "" {
+		link = fmt.Sprintf(`<b>%v</b>`, name)
+	} else {
+		link = fmt.Sprintf(`<a href="%v">%v</a>`, url, name)
+	}
 	if notFirst {
 		link = " / " + link
 	}
 	return link
 }
 
-// buildIndexTemplate constructs the HTML template array, minifies and hashes it
-func buildIndexTemplate(
+// buildPageTemplate constructs the HTML template array, minifies and hashes
+// it. file identifies the page template being built, for error messages.
+func buildPageTemplate(
+	file string,
 	tmpl *template.Template,
-	vars vars,
+	v pageVars,
 	isMobile bool,
 ) (Store, error) {
-	vars.IsMobile = isMobile
+	v.IsMobile = isMobile
 	buffer := new(bytes.Buffer)
-	if err := tmpl.Execute(buffer, vars); err != nil {
-		return Store{}, util.WrapError("Error compiling index template", err)
+	if err := tmpl.ExecuteTemplate(buffer, "layout", v); err != nil {
+		return Store{}, util.WrapError(
+			fmt.Sprintf("Error compiling page template %s", file), err,
+		)
 	}
 	opts := &htmlmin.Options{MinifyScripts: true}
 	minified, err := htmlmin.Minify(buffer.Bytes(), opts)
 	if err != nil {
-		return Store{}, util.WrapError("Error minifying index template", err)
+		return Store{}, util.WrapError(
+			fmt.Sprintf("Error minifying page template %s", file), err,
+		)
 	}
 	hash, err := util.HashBuffer(minified)
 	if err != nil {
-		return Store{}, util.WrapError("Error hashing index template", err)
+		return Store{}, util.WrapError(
+			fmt.Sprintf("Error hashing page template %s", file), err,
+		)
 	}
 	return Store{minified, hash}, nil
 }
 
-// Get retrieves a compiled template by its name
-func Get(name string) Store {
+// Get retrieves a compiled template by name, optionally narrowed with
+// WithBoard, WithLocale or WithMobile. Omitted options default to the
+// board-agnostic, default-locale, desktop variant.
+func Get(name string, opts ...Option) Store {
+	o := getOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	mu.RLock()
 	defer mu.RUnlock()
-	return resources[name]
+	return resources[Key{Page: name, Board: o.board, Locale: o.locale, Mobile: o.mobile}]
 }
 
 // Set sets a template to the specified value. Only use in tests.
-func Set(name string, s Store) {
+func Set(name string, s Store, opts ...Option) {
+	o := getOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	mu.Lock()
 	defer mu.Unlock()
-	resources[name] = s
+	resources[Key{Page: name, Board: o.board, Locale: o.locale, Mobile: o.mobile}] = s
 }
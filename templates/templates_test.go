@@ -0,0 +1,46 @@
+package templates
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// testPagesFS builds an in-memory FS with a minimal base.html and one
+// content file per page RegisterPage has registered, so c.Compile()
+// succeeds without needing the real templates on disk.
+func testPagesFS() fstest.MapFS {
+	fsys := fstest.MapFS{
+		"base.html": &fstest.MapFile{
+			Data: []byte(
+				`{{define "layout"}}<html><body>` +
+					`{{block "content" .}}{{end}}` +
+					`</body></html>{{end}}`,
+			),
+		},
+	}
+
+	pagesMu.RLock()
+	defer pagesMu.RUnlock()
+	for _, p := range pages {
+		fsys[p.file] = &fstest.MapFile{
+			Data: []byte(`{{define "content"}}hello{{end}}`),
+		}
+	}
+	return fsys
+}
+
+func TestCompileAndGet(t *testing.T) {
+	c := &Compiler{FS: testPagesFS(), Assets: fstest.MapFS{}}
+
+	if err := c.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := Get("index")
+	if len(s.HTML) == 0 {
+		t.Fatal("expected compiled HTML, got none")
+	}
+	if s.Hash == "" {
+		t.Fatal("expected a template hash, got none")
+	}
+}
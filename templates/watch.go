@@ -0,0 +1,64 @@
+package templates
+
+import (
+	"log"
+
+	"github.com/bakape/meguca/util"
+	"github.com/fsnotify/fsnotify"
+)
+
+// OnReload, when set, is called after templates have been recompiled as a
+// result of a filesystem change in development mode. The websockets package
+// hooks into this to notify connected clients that they should refresh.
+var OnReload func()
+
+// watch starts watching c.Root for changes and recompiles affected stores
+// on the fly, so edits to index.html or its partials take effect without
+// restarting the server. Only called in development mode; the production
+// hot path still reads straight from the resources map, with no stat
+// calls. A no-op for Compilers with no on-disk Root, such as ones backed
+// by an embedded FileSystem.
+func (c *Compiler) watch() error {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	if c.watcher != nil || c.Root == "" { // already watching, or nothing to watch
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return util.WrapError("error starting template watcher", err)
+	}
+	if err := w.Add(c.Root); err != nil {
+		w.Close()
+		return util.WrapError("error watching template root", err)
+	}
+	c.watcher = w
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if err := c.compile(); err != nil {
+					log.Printf("templates: error recompiling: %s\n", err)
+					continue
+				}
+				if OnReload != nil {
+					OnReload()
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("templates: watcher error: %s\n", err)
+			}
+		}
+	}()
+	return nil
+}
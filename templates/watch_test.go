@@ -0,0 +1,54 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWatchRecompilesOnChange(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("base.html", `{{define "layout"}}<html><body>`+
+		`{{block "content" .}}{{end}}</body></html>{{end}}`)
+
+	pagesMu.RLock()
+	for _, p := range pages {
+		write(p.file, `{{define "content"}}v1{{end}}`)
+	}
+	pagesMu.RUnlock()
+
+	c := &Compiler{
+		FS:      os.DirFS(dir),
+		Root:    dir,
+		Assets:  fstest.MapFS{},
+		DevMode: true,
+	}
+	if err := c.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	OnReload = func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}
+	defer func() { OnReload = nil }()
+
+	write("index.html", `{{define "content"}}v2{{end}}`)
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the filesystem watcher to recompile")
+	}
+}